@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+// Authenticator prepares credentials for talking to a Jupyter server.
+// It is invoked once, before both CreateTerminal and Connect, and
+// returns the extra HTTP header and cookie jar that should accompany
+// every subsequent request, including the websocket upgrade. The
+// returned baseURL is the URL that CreateTerminal/Connect should build
+// requests against from then on; it is empty when the original baseURL
+// passed in still applies, and non-empty for authenticators like
+// HubAuth that are proxied under a different path.
+type Authenticator interface {
+	Authenticate(baseURL string) (header http.Header, jar *cookiejar.Jar, effectiveBaseURL string, err error)
+}
+
+// TokenAuth authenticates using Jupyter's `Authorization: token <t>`
+// header, the default scheme for `jupyter notebook`/`jupyter lab`.
+type TokenAuth struct {
+	Token string
+}
+
+// Authenticate implements Authenticator.
+func (a TokenAuth) Authenticate(baseURL string) (http.Header, *cookiejar.Jar, string, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	header := http.Header{}
+	if a.Token != "" {
+		header.Set("Authorization", fmt.Sprintf("token %s", a.Token))
+	}
+
+	return header, jar, "", nil
+}
+
+// PasswordAuth logs in via `POST /login` using Jupyter's `_xsrf` cookie
+// flow, for servers started with `--NotebookApp.password` or
+// `--ServerApp.password`.
+type PasswordAuth struct {
+	Password string
+}
+
+// Authenticate implements Authenticator.
+func (a PasswordAuth) Authenticate(baseURL string) (http.Header, *cookiejar.Jar, string, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	client := &http.Client{Jar: jar}
+	loginURL := fmt.Sprintf("%s/login", baseURL)
+
+	// GET /login first so the server sets the _xsrf cookie we must echo
+	// back on the POST.
+	resp, err := client.Get(loginURL)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	resp.Body.Close()
+
+	xsrf := cookieValue(jar, loginURL, "_xsrf")
+	if xsrf == "" {
+		return nil, nil, "", fmt.Errorf("no _xsrf cookie returned by %s", loginURL)
+	}
+
+	form := url.Values{}
+	form.Set("_xsrf", xsrf)
+	form.Set("password", a.Password)
+
+	req, err := http.NewRequest("POST", loginURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-XSRFToken", xsrf)
+
+	resp, err = client.Do(req)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusFound {
+		return nil, nil, "", fmt.Errorf("login failed: %s", resp.Status)
+	}
+
+	header := http.Header{}
+	header.Set("X-XSRFToken", xsrf)
+	return header, jar, "", nil
+}
+
+// HubAuth exchanges a JupyterHub API token for a single-user server
+// session cookie by hitting the hub's server activation endpoint, the
+// flow required by JupyterHub-fronted deployments.
+type HubAuth struct {
+	HubToken string
+	User     string
+}
+
+// Authenticate implements Authenticator. The returned effective base URL
+// points at the user's single-user server (<baseURL>/user/<User>) since
+// JupyterHub only proxies terminal/websocket requests under that path,
+// not at the hub root.
+func (a HubAuth) Authenticate(baseURL string) (http.Header, *cookiejar.Jar, string, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	client := &http.Client{Jar: jar}
+
+	activateURL := fmt.Sprintf("%s/hub/api/users/%s/server", baseURL, a.User)
+	req, err := http.NewRequest("POST", activateURL, nil)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("token %s", a.HubToken))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusConflict:
+		// OK or already running.
+	default:
+		return nil, nil, "", fmt.Errorf("failed to start server for %s: %s", a.User, resp.Status)
+	}
+
+	userBaseURL := fmt.Sprintf("%s/user/%s", baseURL, a.User)
+
+	// Visiting the single-user server sets its session cookie in the
+	// shared jar, which CreateTerminal/Connect reuse.
+	resp, err = client.Get(userBaseURL + "/")
+	if err != nil {
+		return nil, nil, "", err
+	}
+	resp.Body.Close()
+
+	header := http.Header{}
+	header.Set("Authorization", fmt.Sprintf("token %s", a.HubToken))
+	return header, jar, userBaseURL, nil
+}
+
+// cookieValue returns the value of the named cookie jar has stored for
+// rawURL, or "" if it isn't set.
+func cookieValue(jar *cookiejar.Jar, rawURL, name string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	for _, c := range jar.Cookies(u) {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// execSentinelRe matches the exit-code sentinel appended to every Exec
+// command, e.g. "__JES_EXIT__:0:__END__".
+var execSentinelRe = regexp.MustCompile(`__JES_EXIT__:(\d+):__END__`)
+
+// Exec runs cmd non-interactively and returns its captured stdout and
+// exit code. It wraps cmd so the shell reports its own exit status,
+// then scans incoming stdout frames until the sentinel shows up.
+func (c *JupyterClient) Exec(ctx context.Context, cmd string) (stdout string, exitCode int, err error) {
+	wrapped := fmt.Sprintf("sh -c '%s'; printf \"\\n__JES_EXIT__:$?:__END__\\n\"", cmd)
+
+	ch := make(chan string, 16)
+	c.execMu.Lock()
+	c.execCh = ch
+	c.execMu.Unlock()
+	defer func() {
+		c.execMu.Lock()
+		c.execCh = nil
+		c.execMu.Unlock()
+	}()
+
+	if err := c.SendCommand(wrapped); err != nil {
+		return "", 0, err
+	}
+
+	var buf strings.Builder
+	for {
+		select {
+		case <-ctx.Done():
+			return buf.String(), 0, ctx.Err()
+		case chunk, ok := <-ch:
+			if !ok {
+				return buf.String(), 0, fmt.Errorf("connection closed before exit sentinel")
+			}
+
+			buf.WriteString(chunk)
+
+			loc := execSentinelRe.FindStringSubmatchIndex(buf.String())
+			if loc == nil {
+				continue
+			}
+
+			full := buf.String()
+			code, err := strconv.Atoi(full[loc[2]:loc[3]])
+			if err != nil {
+				return "", 0, err
+			}
+
+			return full[:loc[0]], code, nil
+		}
+	}
+}
@@ -2,54 +2,111 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"golang.org/x/term"
 )
 
 // Message represents a Jupyter terminal WebSocket message
 type Message []interface{}
 
+const (
+	// writeWait is the time allowed to write a message to the peer.
+	writeWait = 10 * time.Second
+
+	// pongWait is the time allowed to read the next pong message from
+	// the peer.
+	pongWait = 60 * time.Second
+
+	// pingPeriod sends pings to the peer with this period. Must be less
+	// than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+)
+
 // JupyterClient handles the connection to Jupyter
 type JupyterClient struct {
-	baseURL   string
-	token     string
+	baseURL    string
+	auth       Authenticator
+	authHeader http.Header
+	jar        *cookiejar.Jar
+	authDone   bool
 	terminalID string
-	conn      *websocket.Conn
+	conn       *websocket.Conn
+	writeMu    sync.Mutex
+	recorder   *Recorder
+	hub        *ViewerHub
+	execMu     sync.Mutex
+	execCh     chan string
+
+	// KeepaliveInterval controls how often ping frames are sent to keep
+	// the connection alive behind idle-dropping proxies. Defaults to
+	// pingPeriod when zero.
+	KeepaliveInterval time.Duration
 }
 
-// NewJupyterClient creates a new client
-func NewJupyterClient(baseURL, token string) *JupyterClient {
+// NewJupyterClient creates a new client that authenticates with auth.
+func NewJupyterClient(baseURL string, auth Authenticator) *JupyterClient {
 	return &JupyterClient{
 		baseURL: strings.TrimSuffix(baseURL, "/"),
-		token:   token,
+		auth:    auth,
+	}
+}
+
+// ensureAuth runs the configured Authenticator once, caching the header
+// and cookie jar used for every subsequent HTTP and websocket request.
+// If the Authenticator reports an effective base URL (e.g. HubAuth
+// rewriting to the single-user server path), c.baseURL is updated so
+// CreateTerminal/Connect build requests against the right host.
+func (c *JupyterClient) ensureAuth() error {
+	if c.authDone {
+		return nil
+	}
+
+	header, jar, effectiveBaseURL, err := c.auth.Authenticate(c.baseURL)
+	if err != nil {
+		return err
 	}
+
+	c.authHeader = header
+	c.jar = jar
+	if effectiveBaseURL != "" {
+		c.baseURL = effectiveBaseURL
+	}
+	c.authDone = true
+	return nil
 }
 
 // CreateTerminal creates a new terminal session
 func (c *JupyterClient) CreateTerminal() error {
+	if err := c.ensureAuth(); err != nil {
+		return fmt.Errorf("authentication failed: %v", err)
+	}
+
 	url := fmt.Sprintf("%s/api/terminals", c.baseURL)
-	
+
 	req, err := http.NewRequest("POST", url, nil)
 	if err != nil {
 		return err
 	}
-	
-	// Add token if provided
-	if c.token != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", c.token))
+
+	for k, v := range c.authHeader {
+		req.Header[k] = v
 	}
-	
-	client := &http.Client{}
+
+	client := &http.Client{Jar: c.jar}
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -79,42 +136,78 @@ func (c *JupyterClient) CreateTerminal() error {
 
 // Connect establishes WebSocket connection to the terminal
 func (c *JupyterClient) Connect() error {
+	if err := c.ensureAuth(); err != nil {
+		return fmt.Errorf("authentication failed: %v", err)
+	}
+
 	// Convert HTTP URL to WebSocket URL
 	u, err := url.Parse(c.baseURL)
 	if err != nil {
 		return err
 	}
-	
+
 	if u.Scheme == "https" {
 		u.Scheme = "wss"
 	} else {
 		u.Scheme = "ws"
 	}
-	
+
 	u.Path = fmt.Sprintf("/terminals/websocket/%s", c.terminalID)
-	
-	// Add token to query parameters if provided
-	if c.token != "" {
-		q := u.Query()
-		q.Set("token", c.token)
-		u.RawQuery = q.Encode()
-	}
-	
+
 	fmt.Printf("Connecting to: %s\n", u.String())
-	
-	// Create WebSocket connection
-	dialer := websocket.DefaultDialer
+
+	// Create WebSocket connection, threading the authenticator's cookies
+	// and headers into both the jar and the upgrade request.
+	dialer := *websocket.DefaultDialer
+	dialer.Jar = c.jar
+
 	header := http.Header{}
-	
+	for k, v := range c.authHeader {
+		header[k] = v
+	}
+
 	conn, _, err := dialer.Dial(u.String(), header)
 	if err != nil {
 		return fmt.Errorf("websocket dial error: %v", err)
 	}
-	
+
 	c.conn = conn
+
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	go c.keepalive()
+
 	return nil
 }
 
+// keepalive periodically sends ping frames so that Jupyter servers
+// behind proxies that drop idle connections don't silently close the
+// session. It stops once writing a ping fails, which happens once the
+// connection is closed.
+func (c *JupyterClient) keepalive() {
+	interval := c.KeepaliveInterval
+	if interval <= 0 {
+		interval = pingPeriod
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.writeMu.Lock()
+		c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		err := c.conn.WriteMessage(websocket.PingMessage, nil)
+		c.writeMu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
 // ReadMessages handles incoming messages from the terminal
 func (c *JupyterClient) ReadMessages() {
 	for {
@@ -144,7 +237,32 @@ func (c *JupyterClient) ReadMessages() {
 		switch msgType {
 		case "stdout":
 			if output, ok := msg[1].(string); ok {
-				fmt.Print(output)
+				c.execMu.Lock()
+				execCh := c.execCh
+				c.execMu.Unlock()
+
+				if execCh != nil {
+					// Non-blocking: if Exec already returned (timeout or
+					// sentinel matched) and abandoned this channel, a
+					// stuck/slow remote command must never wedge this,
+					// the sole reader goroutine.
+					select {
+					case execCh <- output:
+					default:
+						log.Printf("dropping output for abandoned exec")
+					}
+				} else {
+					fmt.Print(output)
+				}
+
+				if c.recorder != nil {
+					if err := c.recorder.WriteOutput(output); err != nil {
+						log.Printf("failed to record output: %v", err)
+					}
+				}
+				if c.hub != nil {
+					c.hub.Broadcast(output)
+				}
 			}
 		case "setup":
 			log.Println("Terminal ready")
@@ -161,13 +279,30 @@ func (c *JupyterClient) SendCommand(cmd string) error {
 	if !strings.HasSuffix(cmd, "\n") {
 		cmd += "\n"
 	}
-	
-	msg := Message{"stdin", cmd}
+
+	if c.recorder != nil {
+		if err := c.recorder.WriteInput(cmd); err != nil {
+			log.Printf("failed to record input: %v", err)
+		}
+	}
+
+	return c.writeMessage(Message{"stdin", cmd})
+}
+
+// writeMessage marshals msg and writes it to the websocket connection.
+// Writes are serialized behind writeMu so the keepalive goroutine and
+// callers like SendCommand never race on the same connection, and each
+// write is bounded by writeWait.
+func (c *JupyterClient) writeMessage(msg Message) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
-	
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 	return c.conn.WriteMessage(websocket.TextMessage, data)
 }
 
@@ -223,14 +358,74 @@ func (c *JupyterClient) InteractiveShell() {
 
 func main() {
 	var (
-		serverURL = flag.String("url", "http://localhost:8888", "Jupyter server URL")
-		token     = flag.String("token", "", "Jupyter authentication token (optional)")
-		termID    = flag.String("term", "", "Existing terminal ID (optional)")
+		serverURL   = flag.String("url", "http://localhost:8888", "Jupyter server URL")
+		token       = flag.String("token", os.Getenv("JUPYTER_TOKEN"), "Jupyter authentication token (optional, env JUPYTER_TOKEN)")
+		password    = flag.String("password", os.Getenv("JUPYTER_PASSWORD"), "Jupyter notebook password (optional, env JUPYTER_PASSWORD)")
+		hubUser     = flag.String("hub-user", "", "JupyterHub username; exchanges -token for a single-user server cookie via HubAuth")
+		termID      = flag.String("term", "", "Existing terminal ID (optional)")
+		raw         = flag.Bool("raw", term.IsTerminal(int(os.Stdin.Fd())), "Use raw PTY mode with resize propagation (default: on when stdin is a tty)")
+		record      = flag.String("record", "", "Record the session to an asciicast v2 file")
+		play        = flag.String("play", "", "Replay a previously recorded asciicast v2 file and exit")
+		share       = flag.String("share", "", "Serve a read-only web viewer of the session on the given address (e.g. :8765)")
+		shareWrite  = flag.Bool("share-write", false, "Allow web viewers attached via -share to type into the session")
+		execCmd     = flag.String("exec", "", "Run a command non-interactively and exit with its exit code")
+		execTimeout = flag.Duration("exec-timeout", 30*time.Second, "Timeout for -exec")
 	)
 	flag.Parse()
-	
+
+	if *play != "" {
+		if err := Replay(*play); err != nil {
+			log.Fatalf("Failed to replay %s: %v", *play, err)
+		}
+		return
+	}
+
+	// Pick an authenticator based on the flags/env provided
+	var auth Authenticator
+	switch {
+	case *hubUser != "":
+		auth = HubAuth{HubToken: *token, User: *hubUser}
+	case *password != "":
+		auth = PasswordAuth{Password: *password}
+	default:
+		auth = TokenAuth{Token: *token}
+	}
+
 	// Create client
-	client := NewJupyterClient(*serverURL, *token)
+	client := NewJupyterClient(*serverURL, auth)
+
+	if *record != "" {
+		width, height := 80, 24
+		if w, h, err := term.GetSize(int(os.Stdin.Fd())); err == nil {
+			width, height = w, h
+		}
+
+		recorder, err := NewRecorder(*record, width, height)
+		if err != nil {
+			log.Fatalf("Failed to create recording %s: %v", *record, err)
+		}
+		defer recorder.Close()
+
+		client.recorder = recorder
+	}
+
+	if *share != "" {
+		shareToken, err := GenerateToken()
+		if err != nil {
+			log.Fatalf("Failed to generate share token: %v", err)
+		}
+
+		hub := NewViewerHub(client, shareToken, *shareWrite)
+		client.hub = hub
+
+		go func() {
+			if err := hub.ServeShare(*share); err != nil {
+				log.Fatalf("Share server failed: %v", err)
+			}
+		}()
+
+		fmt.Printf("Shared viewer: http://localhost%s/?token=%s\n", *share, shareToken)
+	}
 	
 	// Create or use existing terminal
 	if *termID != "" {
@@ -251,8 +446,28 @@ func main() {
 	// Start reading messages in background
 	go client.ReadMessages()
 	
-	// Interactive shell or single command mode
-	if flag.NArg() > 0 {
+	// Interactive shell, single command, or non-interactive exec mode
+	if *execCmd != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), *execTimeout)
+		stdout, exitCode, execErr := client.Exec(ctx, *execCmd)
+		cancel()
+
+		fmt.Print(stdout)
+
+		// os.Exit below skips every defer registered in main, so run
+		// cleanup explicitly first: flush any -record output and close
+		// the websocket (which also sends "exit" to the remote terminal).
+		client.Close()
+		if client.recorder != nil {
+			client.recorder.Close()
+		}
+
+		if execErr != nil {
+			log.Printf("exec failed: %v", execErr)
+			os.Exit(1)
+		}
+		os.Exit(exitCode)
+	} else if flag.NArg() > 0 {
 		// Single command mode
 		cmd := strings.Join(flag.Args(), " ")
 		if err := client.SendCommand(cmd); err != nil {
@@ -260,6 +475,11 @@ func main() {
 		}
 		// Wait for output
 		time.Sleep(2 * time.Second)
+	} else if *raw {
+		// Raw PTY mode
+		if err := client.RawShell(); err != nil {
+			log.Printf("raw shell error: %v", err)
+		}
 	} else {
 		// Interactive mode
 		client.InteractiveShell()
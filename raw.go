@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// RawShell puts the local terminal into raw mode and proxies bytes
+// directly between stdin/stdout and the Jupyter terminal websocket,
+// instead of the line-buffered prompt used by InteractiveShell. This is
+// required for interactive programs like vim, htop, or less to work.
+func (c *JupyterClient) RawShell() error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return c.pipeShell()
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			term.Restore(fd, oldState)
+			panic(r)
+		}
+	}()
+	defer term.Restore(fd, oldState)
+
+	c.sendSize()
+	go c.watchResize(fd)
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			if sendErr := c.sendStdin(buf[:n]); sendErr != nil {
+				return sendErr
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// pipeShell is used when stdin isn't a tty (e.g. piped input); there's
+// no raw mode or resize handling to do, so just forward bytes as-is.
+func (c *JupyterClient) pipeShell() error {
+	buf := make([]byte, 4096)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			if sendErr := c.sendStdin(buf[:n]); sendErr != nil {
+				return sendErr
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// sendStdin forwards a raw chunk of stdin to the terminal as a
+// ["stdin", <chunk>] message, bypassing SendCommand's newline handling.
+// It still records the chunk, same as SendCommand, so -record captures
+// stdin in raw mode too.
+func (c *JupyterClient) sendStdin(chunk []byte) error {
+	if c.recorder != nil {
+		if err := c.recorder.WriteInput(string(chunk)); err != nil {
+			log.Printf("failed to record input: %v", err)
+		}
+	}
+
+	return c.writeMessage(Message{"stdin", string(chunk)})
+}
+
+// sendSize reports the current local window size to Jupyter via the
+// ["set_size", rows, cols] control message, and to any attached web
+// viewers so they can size their terminal on connect.
+func (c *JupyterClient) sendSize() {
+	cols, rows, err := term.GetSize(int(os.Stdin.Fd()))
+	if err != nil {
+		return
+	}
+	if err := c.writeMessage(Message{"set_size", rows, cols}); err != nil {
+		log.Printf("failed to send terminal size: %v", err)
+	}
+	if c.hub != nil {
+		c.hub.SetSize(rows, cols)
+	}
+}
+
+// watchResize listens for SIGWINCH and forwards the new window size
+// whenever the local terminal is resized.
+func (c *JupyterClient) watchResize(fd int) {
+	sigCh := make(chan os.Signal, 1)
+	notifyResize(sigCh)
+	for range sigCh {
+		c.sendSize()
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyResize registers sigCh to receive SIGWINCH, delivered whenever the
+// controlling terminal's window size changes.
+func notifyResize(sigCh chan os.Signal) {
+	signal.Notify(sigCh, syscall.SIGWINCH)
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+import "os"
+
+// notifyResize is a no-op on Windows, which has no SIGWINCH; the initial
+// sendSize call at startup still reports the window size once.
+func notifyResize(sigCh chan os.Signal) {}
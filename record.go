@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// asciicastHeader is the first line of an asciicast v2 file. See
+// https://github.com/asciinema/asciinema/blob/master/doc/asciicast-v2.md
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env"`
+}
+
+// Recorder writes every stdout frame (and stdin sent via SendCommand or
+// raw mode's sendStdin) to an asciicast v2 file so a session can be
+// replayed later with Replay.
+type Recorder struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	f     *os.File
+	start time.Time
+}
+
+// NewRecorder creates path and writes the asciicast header line. width
+// and height describe the terminal size to embed in the header.
+func NewRecorder(path string, width, height int) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{
+		w:     bufio.NewWriter(f),
+		f:     f,
+		start: time.Now(),
+	}
+
+	header := asciicastHeader{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: r.start.Unix(),
+		Env: map[string]string{
+			"SHELL": os.Getenv("SHELL"),
+			"TERM":  "xterm-256color",
+		},
+	}
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err := r.w.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// WriteOutput records a chunk of terminal output as an "o" event.
+func (r *Recorder) WriteOutput(data string) error {
+	return r.writeEvent("o", data)
+}
+
+// WriteInput records a chunk of terminal input as an "i" event.
+func (r *Recorder) WriteInput(data string) error {
+	return r.writeEvent("i", data)
+}
+
+func (r *Recorder) writeEvent(kind, data string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elapsed := time.Since(r.start).Seconds()
+	event := []interface{}{elapsed, kind, data}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Close flushes buffered events and closes the underlying file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.w.Flush(); err != nil {
+		r.f.Close()
+		return err
+	}
+
+	return r.f.Close()
+}
+
+// Replay parses an asciicast v2 file written by Recorder and writes its
+// "o" events to stdout, sleeping between events to honor the original
+// timing. Truncated or malformed trailing lines are tolerated and simply
+// stop playback early rather than erroring.
+func Replay(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	if !scanner.Scan() {
+		return fmt.Errorf("empty asciicast file")
+	}
+
+	var header asciicastHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return fmt.Errorf("invalid asciicast header: %w", err)
+	}
+
+	var last float64
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var event []json.RawMessage
+		if err := json.Unmarshal(line, &event); err != nil || len(event) != 3 {
+			break
+		}
+
+		var elapsed float64
+		var kind, data string
+		if err := json.Unmarshal(event[0], &elapsed); err != nil {
+			break
+		}
+		if err := json.Unmarshal(event[1], &kind); err != nil {
+			break
+		}
+		if err := json.Unmarshal(event[2], &data); err != nil {
+			break
+		}
+
+		if kind != "o" {
+			continue
+		}
+
+		if delta := elapsed - last; delta > 0 {
+			time.Sleep(time.Duration(delta * float64(time.Second)))
+		}
+		last = elapsed
+
+		fmt.Print(data)
+	}
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// viewerPage is a minimal xterm.js-based HTML page that connects to /ws
+// and renders whatever stdout frames the Jupyter client fans out.
+const viewerPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>jupyter-shell (shared)</title>
+  <link rel="stylesheet" href="https://unpkg.com/xterm@5/css/xterm.css" />
+  <style>html,body{margin:0;height:100%;background:#000}#term{height:100%}</style>
+</head>
+<body>
+  <div id="term"></div>
+  <script src="https://unpkg.com/xterm@5/lib/xterm.js"></script>
+  <script>
+    const term = new Terminal();
+    term.open(document.getElementById('term'));
+
+    const url = new URL('/ws', window.location.href);
+    url.protocol = url.protocol.replace('http', 'ws');
+    url.search = window.location.search;
+    const ws = new WebSocket(url.toString());
+
+    ws.onmessage = (ev) => {
+      const msg = JSON.parse(ev.data);
+      if (msg.type === 'output') {
+        term.write(msg.data);
+      } else if (msg.type === 'size') {
+        term.resize(msg.cols, msg.rows);
+      }
+    };
+
+    term.onData((data) => {
+      ws.send(JSON.stringify({type: 'input', data: data}));
+    });
+
+    window.addEventListener('resize', () => {
+      ws.send(JSON.stringify({type: 'resize', rows: term.rows, cols: term.cols}));
+    });
+  </script>
+</body>
+</html>`
+
+// viewerMessage is the protocol spoken over /ws between the share server
+// and each browser viewer: "output" frames carry terminal output,
+// "size" reports the initial/current terminal dimensions, "input" and
+// "resize" are sent by the viewer (only honored when write access is
+// enabled).
+type viewerMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+	Rows int    `json:"rows,omitempty"`
+	Cols int    `json:"cols,omitempty"`
+}
+
+// viewer is a single attached read-only (or read-write) websocket client.
+type viewer struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+// ViewerHub fans every stdout frame from a JupyterClient out to
+// attached web viewers, modeled on tty-share's sender/server split.
+type ViewerHub struct {
+	client     *JupyterClient
+	token      string
+	allowWrite bool
+
+	mu      sync.RWMutex
+	viewers map[*viewer]bool
+	rows    int
+	cols    int
+
+	upgrader websocket.Upgrader
+}
+
+// NewViewerHub creates a hub that broadcasts client's output to viewers
+// authenticated with token. When allowWrite is true, viewer input is
+// forwarded back to the terminal as raw stdin via client.sendStdin.
+func NewViewerHub(client *JupyterClient, token string, allowWrite bool) *ViewerHub {
+	return &ViewerHub{
+		client:     client,
+		token:      token,
+		allowWrite: allowWrite,
+		viewers:    make(map[*viewer]bool),
+		upgrader:   websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+	}
+}
+
+// GenerateToken returns a random hex token suitable for the viewer URL.
+func GenerateToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Broadcast fans output out to every attached viewer. Slow viewers are
+// dropped rather than allowed to block the Jupyter read loop.
+func (h *ViewerHub) Broadcast(output string) {
+	data, err := json.Marshal(viewerMessage{Type: "output", Data: output})
+	if err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for v := range h.viewers {
+		select {
+		case v.send <- data:
+		default:
+			log.Printf("dropping slow viewer")
+			v.conn.Close()
+		}
+	}
+}
+
+// SetSize records the current terminal size, sent to every viewer that
+// attaches from now on. Called from the session side (sendSize/resize
+// events) and from viewer-initiated resizes.
+func (h *ViewerHub) SetSize(rows, cols int) {
+	h.mu.Lock()
+	h.rows, h.cols = rows, cols
+	h.mu.Unlock()
+}
+
+func (h *ViewerHub) addViewer(v *viewer) {
+	h.mu.Lock()
+	h.viewers[v] = true
+	rows, cols := h.rows, h.cols
+	h.mu.Unlock()
+
+	if rows > 0 && cols > 0 {
+		if data, err := json.Marshal(viewerMessage{Type: "size", Rows: rows, Cols: cols}); err == nil {
+			select {
+			case v.send <- data:
+			default:
+			}
+		}
+	}
+}
+
+func (h *ViewerHub) removeViewer(v *viewer) {
+	h.mu.Lock()
+	delete(h.viewers, v)
+	h.mu.Unlock()
+	close(v.send)
+}
+
+// ServeShare starts the local HTTP server exposing the viewer page and
+// the /ws fan-out endpoint on addr (e.g. ":8765").
+func (h *ViewerHub) ServeShare(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", h.handleIndex)
+	mux.HandleFunc("/ws", h.handleWS)
+	return http.ListenAndServe(addr, mux)
+}
+
+func (h *ViewerHub) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("token") != h.token {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, viewerPage)
+}
+
+func (h *ViewerHub) handleWS(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("token") != h.token {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("viewer upgrade failed: %v", err)
+		return
+	}
+
+	v := &viewer{conn: conn, send: make(chan []byte, 256)}
+	h.addViewer(v)
+	defer h.removeViewer(v)
+
+	go h.writeLoop(v)
+	h.readLoop(v)
+}
+
+func (h *ViewerHub) writeLoop(v *viewer) {
+	for data := range v.send {
+		if err := v.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			return
+		}
+	}
+}
+
+func (h *ViewerHub) readLoop(v *viewer) {
+	defer v.conn.Close()
+
+	for {
+		_, data, err := v.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		if !h.allowWrite {
+			continue
+		}
+
+		var msg viewerMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+
+		switch msg.Type {
+		case "input":
+			if err := h.client.sendStdin([]byte(msg.Data)); err != nil {
+				log.Printf("failed to forward viewer input: %v", err)
+			}
+		case "resize":
+			h.SetSize(msg.Rows, msg.Cols)
+			if err := h.client.writeMessage(Message{"set_size", msg.Rows, msg.Cols}); err != nil {
+				log.Printf("failed to forward viewer resize: %v", err)
+			}
+		}
+	}
+}